@@ -1,272 +1,1366 @@
-package main
-
-import (
-	"encoding/json"
-	"fmt"
-	"strconv"
-
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	"github.com/hyperledger/fabric/protos/peer"
-)
-
-type SmartContract struct {
-
-}
-
-// ----- Wallet ----- //
-type Wallet struct {
-	Value 		uint64 			`json:"value"`		// Balance
-	Transfer	TransferInfo	`json:"transfer`	// Transfer Information
-}
-
-// ----- Transfer information ----- //
-type TransferInfo struct {
-	FromOrTo	string 	`json:"fromOrTo"`	// Collaborator
-	Value 		uint64 	`json:"value"`		// Remittance amount
-	Date 		string 	`json:"date"`		// Transfer Date
-	TxType 		string 	`json:"type"`		// Transfer Type	0: Publish(By Admin)
-											// 					1: Payment(By Sender) 				2: Payment(By Recipient)
-											// 					3: Cancel Payment(By Sender) 		4: Cancel Payment(By Recipient)	
-											// 					5: Remittance(By Sender), 			6: Remittance(By Recipient)
-											// 					7: Cancel Remittance(By Sender) 	8: Cancel Remittance(By Recipient)	
-}
-
-// ============================================================================================================================
-// 	Main
-// ============================================================================================================================
-func main() {
-	err := shim.Start(new(SmartContract))
-	if err != nil {
-		fmt.Printf("Error creating new Smart Contract: %s", err)
-	}
-}
-
-// ============================================================================================================================
-// 	Init
-// ============================================================================================================================
-func (s *SmartContract) Init(stub shim.ChaincodeStubInterface) peer.Response {
-	return shim.Success(nil)
-}
-
-// ============================================================================================================================
-// 	Invoke
-//	init_wallet	:	invoke '{"Args":["init_wallet", "1"]}'
-//	publish		:	invoke '{"Args":["publish", "1", "10", "10000", "20181212"]}'
-//	transfer	:	invoke '{"Args":["transfer", "1", "2", "1000", "3", "20181212"]}'
-//	get_account	:	query '{"Args":["get_account", "1"]}'
-//	get_txList	:	query '{"Args":["get_txList", "1"]}'
-// ============================================================================================================================
-func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
-	function, args := stub.GetFunctionAndParameters()
-
-	if function == "init" {
-		return s.Init(stub)
-	} else if function == "init_wallet" {
-		return init_wallet(stub, args)
-	} else if function == "publish" {
-		return publish(stub, args)
-	} else if function == "transfer" {
-		return transfer(stub, args)
-	} else if function == "get_account" {
-		return get_account(stub, args)
-	} else if function == "get_txList" {
-		return get_txList(stub, args)
-	}
-
-	return shim.Error(fmt.Sprintf("Received unknown invoke function name: %s", function));
-}
-
-// ============================================================================================================================
-//	init_wallet
-//	- params: key
-//	- return: walletAsBytes
-// ============================================================================================================================
-func init_wallet(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1")
-	}
-
-	var newWallet = Wallet {
-		Value 		: 0,
-	}
-
-	walletAsBytes, _ := json.Marshal(newWallet)
-	err := stub.PutState(args[0], walletAsBytes)
-
-	if (err != nil) {
-		return shim.Error(fmt.Sprintf("Failed to create Wallet: %s", args[0]));
-	}
-
-	return shim.Success(walletAsBytes)
-}
-
-// ============================================================================================================================
-//	publish
-//	- params: key, from, value, date
-//	- return: walletAsBytes
-// ============================================================================================================================
-func publish(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	var wallet Wallet
-	
-	if len(args) != 4 {
-		return shim.Error("Incorrect number of arguments. Expecting 4")
-	}
-
-	walletAsBytes, _ := stub.GetState(args[0])
-	if walletAsBytes == nil {
-		return shim.Error("Not Found wallet : %s", )
-	}
-	
-	json.Unmarshal(walletAsBytes, &wallet)
-	value, _ := strconv.ParseUint(args[2], 10, 32)
-	
-	wallet.Value += value
-	wallet.Transfer.FromOrTo = args[1]
-	wallet.Transfer.Value = value
-	wallet.Transfer.TxType = "0"	// 0 is publish
-	wallet.Transfer.Date = args[3]
-
-	walletAsBytes, _ = json.Marshal(wallet)
-	err := stub.PutState(args[0], walletAsBytes)
-	if (err != nil) {
-		return shim.Error("Failed to publish");
-	}
-
-	return shim.Success(walletAsBytes)
-}
-
-// ============================================================================================================================
-//	transfer
-//	- params: key, Collaborator, value, transfer_type, date
-//	- return: txid
-// ============================================================================================================================
-func transfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 5 {
-		return shim.Error("Incorrect number of arguments. Expecting 5")
-	}
-
-	from := Wallet{}
-	to := Wallet{}
-
-	fromAsBytes, _ := stub.GetState(args[0])
-	toAsBytes, _ := stub.GetState(args[1])
-
-	value, _ := strconv.ParseUint(args[2], 10, 32)
-	toType, _ := strconv.Atoi(args[3])
-	toType += 1
-	fromType := strconv.Itoa(toType)
-
-	if fromAsBytes == nil || toAsBytes == nil {
-		return shim.Error("Not found wallet")
-	}
-
-	json.Unmarshal(fromAsBytes, &from)
-	json.Unmarshal(toAsBytes, &to)
-	
-	if from.Value < value {
-		return shim.Error(fmt.Sprintf("%s is not enough balance.", args[0]))
-	}
-	
-	from.Value -= value
-	from.Transfer.FromOrTo = args[1]
-	from.Transfer.Value = value
-	from.Transfer.TxType = args[3]
-	from.Transfer.Date = args[4]
-
-	to.Value += value
-	to.Transfer.FromOrTo = args[0]
-	to.Transfer.Value = value
-	to.Transfer.TxType = fromType
-	to.Transfer.Date = args[4]
-
-	fromAsBytes, _ = json.Marshal(from)
-	toAsBytes, _ = json.Marshal(to)
-
-	err := stub.PutState(args[0], fromAsBytes)
-	if (err != nil) {
-		return shim.Error(fmt.Sprintf("Failed to transfer: %s", err.Error));
-	}
-
-	txid := stub.GetTxID()
-
-	err = stub.PutState(args[1], toAsBytes)
-	if (err != nil) {
-		return shim.Error(fmt.Sprintf("Failed to transfer: %s", err.Error));
-	}
-
-	return shim.Success([]byte(txid))
-}
-
-// ============================================================================================================================
-// 	get_account
-//	- params: key
-//	- return: value
-// ============================================================================================================================
-func get_account(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	var wallet Wallet
-	
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1")
-	 }
-  
-	 walletAsBytes, _ := stub.GetState(args[0]);
-	 if walletAsBytes == nil {
-		return shim.Error("Could not locate Wallet")
-	 }
-
-	 json.Unmarshal(walletAsBytes, &wallet)
-	 value := fmt.Sprint(wallet.Value)
-
-	 return shim.Success([]byte(value))
-}
-
-// ============================================================================================================================
-// 	get_txList
-//	- params: key
-//	- return: []historyAsBytes
-// ============================================================================================================================
-func get_txList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	type get_History struct {
-		TxId    string   	`json:"txId"`
-		Value   Wallet   	`json:"value"`
-	 }
-	 var history []get_History;
-	 var wallet Wallet
-  
-	 if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1")
-	 }
-  
-	 transferId := args[0]
-	 fmt.Printf("- start getHistoryForMarble: %s\n", transferId)
-  
-	 resultsIterator, err := stub.GetHistoryForKey(transferId)
-	 if err != nil {
-		return shim.Error(err.Error())
-	 }
-	 defer resultsIterator.Close()
-  
-	 for resultsIterator.HasNext() {
-		historyData, err := resultsIterator.Next()
-		if err != nil {
-		   return shim.Error(err.Error())
-		}
-  
-		var tx get_History
-		tx.TxId = historyData.TxId                     
-		json.Unmarshal(historyData.Value, &wallet)    
-		if historyData.Value == nil {                 
-		   var emptyWalletHistory Wallet
-		   tx.Value = emptyWalletHistory                
-		} else {
-		   json.Unmarshal(historyData.Value, &wallet) 
-		   tx.Value = wallet                      
-		}
-		history = append(history, tx)   
-	 }
-	 
-	 fmt.Printf("- getHistoryForMarble returning:\n%s", history)
-  
-	 historyAsBytes, _ := json.Marshal(history)     //convert to array of bytes
-	 return shim.Success(historyAsBytes)  
-}
\ No newline at end of file
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+type SmartContract struct {
+
+}
+
+// defaultSymbol is the currency symbol used to migrate wallets that were
+// created before multi-currency support existed (single implicit balance).
+const defaultSymbol = "DEFAULT"
+
+// State keys used for the global lock / scheduled halt mechanism.
+const adminKey = "__admin__"
+const lockKey = "__locked__"
+const haltHeightsKey = "__haltHeights__"
+const txHeightKey = "__txHeight__"
+
+// ----- Wallet ----- //
+// Kept lean on purpose: per-transfer detail lives in the Transaction
+// subsystem below, not on the wallet itself.
+type Wallet struct {
+	Balances			map[string]uint64	`json:"balances"`			// Per-symbol balance
+	Locked				map[string]uint64	`json:"locked"`				// Per-symbol balance held by pending/proposed transfers
+	ApprovalThreshold	uint64				`json:"approvalThreshold"`	// Transfers >= this value are proposed, not executed (0 disables gating)
+	RequiredApprovals	uint32				`json:"requiredApprovals"`	// Distinct approver identities needed to release a proposed transfer (0 is treated as 1)
+	Approvers			[]string			`json:"approvers"`			// base64 creator identities authorized to call approve_transfer on this wallet
+}
+
+// UnmarshalJSON migrates wallets that were persisted before multi-currency
+// support (a bare "value" balance) into the per-symbol Balances map.
+func (w *Wallet) UnmarshalJSON(data []byte) error {
+	type currentWallet Wallet
+	var cw currentWallet
+	if err := json.Unmarshal(data, &cw); err == nil && cw.Balances != nil {
+		*w = Wallet(cw)
+		return nil
+	}
+
+	var legacy struct {
+		Value	uint64	`json:"value"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	w.Balances = map[string]uint64{defaultSymbol: legacy.Value}
+	return nil
+}
+
+// ----- Transaction ----- //
+// Every publish/transfer is persisted as its own Transaction record under
+// composite key tx~{owner}~{timestamp}~{txid}, once per wallet it touches,
+// so a wallet's history can be queried directly instead of reconstructed.
+type Transaction struct {
+	TxID	string	`json:"txId"`
+	From	string	`json:"from"`
+	To		string	`json:"to"`
+	Value	uint64	`json:"value"`
+	TxType	string	`json:"type"`	// Transfer Type	0: Publish(By Currency Owner)
+									// 					1: Payment(By Sender) 				2: Payment(By Recipient)
+									// 					3: Cancel Payment(By Sender) 		4: Cancel Payment(By Recipient)
+									// 					5: Remittance(By Sender), 			6: Remittance(By Recipient)
+									// 					7: Cancel Remittance(By Sender) 	8: Cancel Remittance(By Recipient)
+	Date	string	`json:"date"`
+	Symbol	string	`json:"symbol"`
+	Fee		uint64	`json:"fee"`
+	Memo	string	`json:"memo"`
+}
+
+// ----- Currency ----- //
+type Currency struct {
+	Name		string	`json:"name"`		// Display name
+	Symbol		string	`json:"symbol"`		// Unique symbol, e.g. "KRW"
+	TotalSupply	uint64	`json:"totalSupply"`// Total amount in circulation
+	Owner		string	`json:"owner"`		// base64 of the creator identity that registered the currency
+	Decimals	uint32	`json:"decimals"`	// Number of decimal places
+}
+
+// ----- Pending transfer ----- //
+// Written by transfer_pending and consumed by exactly one of transfer_commit
+// or transfer_cancel; its absence is what rejects a double-commit/-cancel.
+type PendingTransfer struct {
+	TxID	string	`json:"txId"`
+	From	string	`json:"from"`
+	To		string	`json:"to"`
+	Value	uint64	`json:"value"`
+	Fee		uint64	`json:"fee"`
+	TxType	string	`json:"type"`
+	Date	string	`json:"date"`
+	Symbol	string	`json:"symbol"`
+}
+
+// coinbaseWalletKey is the wallet fees are routed to on transfer_commit.
+const coinbaseWalletKey = "__coinbase__"
+
+// ----- Proposal ----- //
+// Written by propose_transfer (directly, or via transfer once value crosses
+// the sender's ApprovalThreshold) and released once Required distinct
+// approvers have called approve_transfer.
+type Proposal struct {
+	TxID		string		`json:"txId"`
+	From		string		`json:"from"`
+	To			string		`json:"to"`
+	Value		uint64		`json:"value"`
+	TxType		string		`json:"type"`
+	Date		string		`json:"date"`
+	Symbol		string		`json:"symbol"`
+	Required	uint32		`json:"required"`
+	Approvers	[]string	`json:"approvers"`	// base64 creator identities that have approved
+}
+
+// ============================================================================================================================
+// 	Main
+// ============================================================================================================================
+func main() {
+	err := shim.Start(new(SmartContract))
+	if err != nil {
+		fmt.Printf("Error creating new Smart Contract: %s", err)
+	}
+}
+
+// ============================================================================================================================
+// 	Init
+//	- params: haltHeight...
+//	- records the instantiating identity as admin and persists any scheduled halt-block heights
+// ============================================================================================================================
+func (s *SmartContract) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	_, args := stub.GetFunctionAndParameters()
+
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return shim.Error("Failed to read instantiating identity")
+	}
+
+	err = stub.PutState(adminKey, []byte(base64.StdEncoding.EncodeToString(creator)))
+	if (err != nil) {
+		return shim.Error("Failed to store admin identity");
+	}
+
+	haltHeights := make([]uint64, 0, len(args))
+	for _, arg := range args {
+		haltHeight, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("Invalid halt-block height: %s", arg))
+		}
+		haltHeights = append(haltHeights, haltHeight)
+	}
+
+	haltHeightsAsBytes, _ := json.Marshal(haltHeights)
+	err = stub.PutState(haltHeightsKey, haltHeightsAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to store halt-block heights");
+	}
+
+	return shim.Success(nil)
+}
+
+// ============================================================================================================================
+//	isAdmin
+//	- compares the invoking identity (stub.GetCreator()) against the identity recorded at Init
+// ============================================================================================================================
+func isAdmin(stub shim.ChaincodeStubInterface) bool {
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return false
+	}
+
+	adminAsBytes, _ := stub.GetState(adminKey)
+	return adminAsBytes != nil && base64.StdEncoding.EncodeToString(creator) == string(adminAsBytes)
+}
+
+// ============================================================================================================================
+//	checkHalted
+//	- returns an error when the chaincode is admin-locked or past a scheduled halt height
+//	- heights are a monotonically-increasing counter stored in state (stub exposes no real block
+//	  height), incremented once per guarded invocation; wall-clock time (stub.GetTxTimestamp) is not
+//	  usable here since Unix epoch seconds already exceed any realistic height value
+// ============================================================================================================================
+func checkHalted(stub shim.ChaincodeStubInterface) error {
+	lockedAsBytes, _ := stub.GetState(lockKey)
+	if lockedAsBytes != nil {
+		locked, _ := strconv.ParseBool(string(lockedAsBytes))
+		if locked {
+			return fmt.Errorf("Chaincode invocations are currently locked")
+		}
+	}
+
+	haltHeightsAsBytes, _ := stub.GetState(haltHeightsKey)
+	var haltHeights []uint64
+	if haltHeightsAsBytes != nil {
+		json.Unmarshal(haltHeightsAsBytes, &haltHeights)
+	}
+
+	heightAsBytes, _ := stub.GetState(txHeightKey)
+	var height uint64
+	if heightAsBytes != nil {
+		height, _ = strconv.ParseUint(string(heightAsBytes), 10, 64)
+	}
+	height++
+
+	if err := stub.PutState(txHeightKey, []byte(strconv.FormatUint(height, 10))); err != nil {
+		return err
+	}
+
+	for _, haltHeight := range haltHeights {
+		if height >= haltHeight {
+			return fmt.Errorf("Chaincode halted at scheduled height %d", haltHeight)
+		}
+	}
+
+	return nil
+}
+
+// ============================================================================================================================
+//	set_lock
+//	- params: locked ("true"/"false")
+//	- return: lockedAsBytes
+//	- admin-only
+// ============================================================================================================================
+func set_lock(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	if !isAdmin(stub) {
+		return shim.Error("Only the admin may change the lock")
+	}
+
+	locked, err := strconv.ParseBool(args[0])
+	if err != nil {
+		return shim.Error("Invalid lock value. Expecting true/false")
+	}
+
+	lockedAsBytes := []byte(strconv.FormatBool(locked))
+	err = stub.PutState(lockKey, lockedAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to update lock");
+	}
+
+	return shim.Success(lockedAsBytes)
+}
+
+// ============================================================================================================================
+// 	Invoke
+//	init_wallet		:	invoke '{"Args":["init_wallet", "1", "1000000", "2", "<approver1-b64>", "<approver2-b64>"]}'
+//	set_lock		:	invoke '{"Args":["set_lock", "true"]}'
+//	init_currency	:	invoke '{"Args":["init_currency", "KRW", "Korean Won", "0"]}'
+//	mint_token		:	invoke '{"Args":["mint_token", "KRW", "1", "10000"]}'
+//	burn_token		:	invoke '{"Args":["burn_token", "KRW", "1", "10000"]}'
+//	publish			:	invoke '{"Args":["publish", "1", "10", "10000", "20181212", "KRW"]}'
+//	transfer		:	invoke '{"Args":["transfer", "1", "2", "1000", "3", "20181212", "KRW"]}'
+//	transfer_pending:	invoke '{"Args":["transfer_pending", "1", "2", "1000", "5", "20181212", "KRW", "10"]}'
+//	transfer_commit	:	invoke '{"Args":["transfer_commit", "<txid>"]}'
+//	transfer_cancel	:	invoke '{"Args":["transfer_cancel", "<txid>"]}'
+//	propose_transfer:	invoke '{"Args":["propose_transfer", "1", "2", "1000000", "3", "20181212", "KRW"]}'
+//	approve_transfer:	invoke '{"Args":["approve_transfer", "<txid>"]}'
+//	get_account		:	query '{"Args":["get_account", "1"]}'
+//	balance_of		:	query '{"Args":["balance_of", "KRW", "1"]}'
+//	get_txList		:	query '{"Args":["get_txList", "1", "10", "", "", "", "", ""]}'
+// ============================================================================================================================
+func (s *SmartContract) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	function, args := stub.GetFunctionAndParameters()
+
+	if function == "init" {
+		return s.Init(stub)
+	} else if function == "init_wallet" {
+		return init_wallet(stub, args)
+	} else if function == "set_lock" {
+		return set_lock(stub, args)
+	} else if function == "init_currency" {
+		return init_currency(stub, args)
+	} else if function == "mint_token" {
+		return mint_token(stub, args)
+	} else if function == "burn_token" {
+		return burn_token(stub, args)
+	} else if function == "publish" {
+		return publish(stub, args)
+	} else if function == "transfer" {
+		return transfer(stub, args)
+	} else if function == "transfer_pending" {
+		return transfer_pending(stub, args)
+	} else if function == "transfer_commit" {
+		return transfer_commit(stub, args)
+	} else if function == "transfer_cancel" {
+		return transfer_cancel(stub, args)
+	} else if function == "propose_transfer" {
+		return propose_transfer(stub, args)
+	} else if function == "approve_transfer" {
+		return approve_transfer(stub, args)
+	} else if function == "get_account" {
+		return get_account(stub, args)
+	} else if function == "balance_of" {
+		return balance_of(stub, args)
+	} else if function == "get_txList" {
+		return get_txList(stub, args)
+	}
+
+	return shim.Error(fmt.Sprintf("Received unknown invoke function name: %s", function));
+}
+
+// ============================================================================================================================
+//	init_wallet
+//	- params: key, approvalThreshold, requiredApprovals, approver...
+//	- a transfer out of this wallet for value >= approvalThreshold is proposed rather than executed immediately,
+//	  and only moves funds once requiredApprovals (at least 1) distinct identities from the approver allow-list
+//	  call approve_transfer (0 threshold disables gating)
+//	- return: walletAsBytes
+// ============================================================================================================================
+func init_wallet(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) < 3 {
+		return shim.Error("Incorrect number of arguments. Expecting at least 3: key, approvalThreshold, requiredApprovals, [approver...]")
+	}
+
+	approvalThreshold, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return shim.Error("Invalid approval threshold")
+	}
+
+	requiredApprovals, err := strconv.ParseUint(args[2], 10, 32)
+	if err != nil {
+		return shim.Error("Invalid required approval count")
+	}
+
+	approvers := args[3:]
+	if requiredApprovals > 0 && uint64(len(approvers)) < requiredApprovals {
+		return shim.Error("Not enough approvers configured to satisfy requiredApprovals")
+	}
+
+	var newWallet = Wallet {
+		Balances			: make(map[string]uint64),
+		ApprovalThreshold	: approvalThreshold,
+		RequiredApprovals	: uint32(requiredApprovals),
+		Approvers			: approvers,
+	}
+
+	walletAsBytes, _ := json.Marshal(newWallet)
+	err = stub.PutState(args[0], walletAsBytes)
+
+	if (err != nil) {
+		return shim.Error(fmt.Sprintf("Failed to create Wallet: %s", args[0]));
+	}
+
+	return shim.Success(walletAsBytes)
+}
+
+// ============================================================================================================================
+//	currencyKey
+//	- builds the composite key a Currency is persisted under: currency~SYMBOL
+// ============================================================================================================================
+func currencyKey(stub shim.ChaincodeStubInterface, symbol string) (string, error) {
+	return stub.CreateCompositeKey("currency", []string{symbol})
+}
+
+// ============================================================================================================================
+//	getCurrency
+//	- looks up a registered Currency by symbol, erroring if it isn't registered
+// ============================================================================================================================
+func getCurrency(stub shim.ChaincodeStubInterface, symbol string) (Currency, error) {
+	var currency Currency
+
+	key, err := currencyKey(stub, symbol)
+	if err != nil {
+		return currency, err
+	}
+
+	currencyAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return currency, err
+	}
+	if currencyAsBytes == nil {
+		return currency, fmt.Errorf("Currency not registered: %s", symbol)
+	}
+
+	json.Unmarshal(currencyAsBytes, &currency)
+	return currency, nil
+}
+
+// ============================================================================================================================
+//	isCurrencyOwner
+//	- compares the invoking identity (stub.GetCreator()) against the currency's registered owner
+// ============================================================================================================================
+func isCurrencyOwner(stub shim.ChaincodeStubInterface, currency Currency) bool {
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return false
+	}
+
+	return base64.StdEncoding.EncodeToString(creator) == currency.Owner
+}
+
+// ============================================================================================================================
+//	init_currency
+//	- params: symbol, name, decimals
+//	- return: currencyAsBytes
+// ============================================================================================================================
+func init_currency(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+
+	key, err := currencyKey(stub, args[0])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to build currency key: %s", err.Error()))
+	}
+
+	existingAsBytes, _ := stub.GetState(key)
+	if existingAsBytes != nil {
+		return shim.Error(fmt.Sprintf("Currency already registered: %s", args[0]))
+	}
+
+	decimals, err := strconv.ParseUint(args[2], 10, 32)
+	if err != nil {
+		return shim.Error("Invalid decimals")
+	}
+
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return shim.Error("Failed to read invoking identity")
+	}
+
+	currency := Currency {
+		Name		: args[1],
+		Symbol		: args[0],
+		TotalSupply	: 0,
+		Owner		: base64.StdEncoding.EncodeToString(creator),
+		Decimals	: uint32(decimals),
+	}
+
+	currencyAsBytes, _ := json.Marshal(currency)
+	err = stub.PutState(key, currencyAsBytes)
+	if (err != nil) {
+		return shim.Error(fmt.Sprintf("Failed to register currency: %s", args[0]));
+	}
+
+	return shim.Success(currencyAsBytes)
+}
+
+// ============================================================================================================================
+//	mint_token
+//	- params: symbol, key, value
+//	- return: walletAsBytes
+//	- only the currency owner may mint
+// ============================================================================================================================
+func mint_token(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var wallet Wallet
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+
+	currency, err := getCurrency(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isCurrencyOwner(stub, currency) {
+		return shim.Error(fmt.Sprintf("Only the owner of %s may mint", args[0]))
+	}
+
+	walletAsBytes, _ := stub.GetState(args[1])
+	if walletAsBytes == nil {
+		return shim.Error("Not Found wallet")
+	}
+
+	json.Unmarshal(walletAsBytes, &wallet)
+	value, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Invalid value: %s", err.Error()))
+	}
+
+	if wallet.Balances == nil {
+		wallet.Balances = make(map[string]uint64)
+	}
+	wallet.Balances[args[0]] += value
+
+	walletAsBytes, _ = json.Marshal(wallet)
+	err = stub.PutState(args[1], walletAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to mint");
+	}
+
+	currency.TotalSupply += value
+	currencyAsBytes, _ := json.Marshal(currency)
+	key, _ := currencyKey(stub, args[0])
+	err = stub.PutState(key, currencyAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to update currency supply");
+	}
+
+	return shim.Success(walletAsBytes)
+}
+
+// ============================================================================================================================
+//	burn_token
+//	- params: symbol, key, value
+//	- return: walletAsBytes
+//	- only the currency owner may burn
+// ============================================================================================================================
+func burn_token(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var wallet Wallet
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3")
+	}
+
+	currency, err := getCurrency(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isCurrencyOwner(stub, currency) {
+		return shim.Error(fmt.Sprintf("Only the owner of %s may burn", args[0]))
+	}
+
+	walletAsBytes, _ := stub.GetState(args[1])
+	if walletAsBytes == nil {
+		return shim.Error("Not Found wallet")
+	}
+
+	json.Unmarshal(walletAsBytes, &wallet)
+	value, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Invalid value: %s", err.Error()))
+	}
+
+	if wallet.Balances[args[0]] < value {
+		return shim.Error(fmt.Sprintf("%s does not have enough %s to burn", args[1], args[0]))
+	}
+
+	wallet.Balances[args[0]] -= value
+
+	walletAsBytes, _ = json.Marshal(wallet)
+	err = stub.PutState(args[1], walletAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to burn");
+	}
+
+	currency.TotalSupply -= value
+	currencyAsBytes, _ := json.Marshal(currency)
+	key, _ := currencyKey(stub, args[0])
+	err = stub.PutState(key, currencyAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to update currency supply");
+	}
+
+	return shim.Success(walletAsBytes)
+}
+
+// ============================================================================================================================
+//	balance_of
+//	- params: symbol, key
+//	- return: value
+// ============================================================================================================================
+func balance_of(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	var wallet Wallet
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2")
+	}
+
+	walletAsBytes, _ := stub.GetState(args[1])
+	if walletAsBytes == nil {
+		return shim.Error("Could not locate Wallet")
+	}
+
+	json.Unmarshal(walletAsBytes, &wallet)
+	value := fmt.Sprint(wallet.Balances[args[0]])
+
+	return shim.Success([]byte(value))
+}
+
+// ============================================================================================================================
+//	recordTx
+//	- persists a Transaction under tx~{owner}~{timestamp}~{txid} so get_txList can query it directly
+// ============================================================================================================================
+func recordTx(stub shim.ChaincodeStubInterface, owner string, tx Transaction) error {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return err
+	}
+
+	key, err := stub.CreateCompositeKey("tx", []string{owner, fmt.Sprintf("%020d", timestamp.Seconds), tx.TxID})
+	if err != nil {
+		return err
+	}
+
+	txAsBytes, _ := json.Marshal(tx)
+	return stub.PutState(key, txAsBytes)
+}
+
+// ============================================================================================================================
+//	publish
+//	- params: key, from, value, date, symbol
+//	- return: walletAsBytes
+//	- only the currency owner may publish (mint) into a wallet
+// ============================================================================================================================
+func publish(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	var wallet Wallet
+
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) != 5 {
+		return shim.Error("Incorrect number of arguments. Expecting 5")
+	}
+
+	currency, err := getCurrency(stub, args[4])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !isCurrencyOwner(stub, currency) {
+		return shim.Error(fmt.Sprintf("Only the owner of %s may publish", args[4]))
+	}
+
+	walletAsBytes, _ := stub.GetState(args[0])
+	if walletAsBytes == nil {
+		return shim.Error("Not Found wallet : %s", )
+	}
+
+	json.Unmarshal(walletAsBytes, &wallet)
+	value, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Invalid value: %s", err.Error()))
+	}
+
+	if wallet.Balances == nil {
+		wallet.Balances = make(map[string]uint64)
+	}
+	wallet.Balances[args[4]] += value
+
+	walletAsBytes, _ = json.Marshal(wallet)
+	err = stub.PutState(args[0], walletAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to publish");
+	}
+
+	currency.TotalSupply += value
+	currencyAsBytes, _ := json.Marshal(currency)
+	key, _ := currencyKey(stub, args[4])
+	err = stub.PutState(key, currencyAsBytes)
+	if (err != nil) {
+		return shim.Error("Failed to update currency supply");
+	}
+
+	tx := Transaction {
+		TxID	: stub.GetTxID(),
+		From	: args[1],
+		To		: args[0],
+		Value	: value,
+		TxType	: "0",	// 0 is publish
+		Date	: args[3],
+		Symbol	: args[4],
+	}
+	if err := recordTx(stub, args[0], tx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	return shim.Success(walletAsBytes)
+}
+
+// ============================================================================================================================
+//	transfer
+//	- params: key, Collaborator, value, transfer_type, date, symbol
+//	- return: txid
+// ============================================================================================================================
+func transfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) != 6 {
+		return shim.Error("Incorrect number of arguments. Expecting 6")
+	}
+
+	symbol := args[5]
+	if _, err := getCurrency(stub, symbol); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	from := Wallet{}
+	to := Wallet{}
+
+	fromAsBytes, _ := stub.GetState(args[0])
+	toAsBytes, _ := stub.GetState(args[1])
+
+	value, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Invalid value: %s", err.Error()))
+	}
+	toType, _ := strconv.Atoi(args[3])
+	toType += 1
+	fromType := strconv.Itoa(toType)
+
+	if fromAsBytes == nil || toAsBytes == nil {
+		return shim.Error("Not found wallet")
+	}
+
+	json.Unmarshal(fromAsBytes, &from)
+	json.Unmarshal(toAsBytes, &to)
+
+	if from.ApprovalThreshold > 0 && value >= from.ApprovalThreshold {
+		return propose_transfer(stub, args)
+	}
+
+	if from.Balances[symbol] < value {
+		return shim.Error(fmt.Sprintf("%s is not enough balance.", args[0]))
+	}
+
+	from.Balances[symbol] -= value
+
+	if to.Balances == nil {
+		to.Balances = make(map[string]uint64)
+	}
+	to.Balances[symbol] += value
+
+	fromAsBytes, _ = json.Marshal(from)
+	toAsBytes, _ = json.Marshal(to)
+
+	err = stub.PutState(args[0], fromAsBytes)
+	if (err != nil) {
+		return shim.Error(fmt.Sprintf("Failed to transfer: %s", err.Error));
+	}
+
+	txid := stub.GetTxID()
+
+	err = stub.PutState(args[1], toAsBytes)
+	if (err != nil) {
+		return shim.Error(fmt.Sprintf("Failed to transfer: %s", err.Error));
+	}
+
+	fromTx := Transaction {
+		TxID	: txid,
+		From	: args[0],
+		To		: args[1],
+		Value	: value,
+		TxType	: args[3],
+		Date	: args[4],
+		Symbol	: symbol,
+	}
+	if err := recordTx(stub, args[0], fromTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	toTx := fromTx
+	toTx.TxType = fromType
+	if err := recordTx(stub, args[1], toTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	return shim.Success([]byte(txid))
+}
+
+// ============================================================================================================================
+//	proposalKey
+//	- builds the composite key a Proposal is persisted under: proposal~TXID
+// ============================================================================================================================
+func proposalKey(stub shim.ChaincodeStubInterface, txid string) (string, error) {
+	return stub.CreateCompositeKey("proposal", []string{txid})
+}
+
+// ============================================================================================================================
+//	creatorIdentity
+//	- base64 of the invoking identity, used to record and de-duplicate approvers
+// ============================================================================================================================
+func creatorIdentity(stub shim.ChaincodeStubInterface) (string, error) {
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(creator), nil
+}
+
+// ============================================================================================================================
+//	propose_transfer
+//	- params: key, Collaborator, value, transfer_type, date, symbol
+//	- return: txid
+//	- locks (value) out of the sender's balance and writes a proposal~{txid} record; funds only move once
+//	  RequiredApprovals (at least 1) distinct identities call approve_transfer
+// ============================================================================================================================
+func propose_transfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) != 6 {
+		return shim.Error("Incorrect number of arguments. Expecting 6")
+	}
+
+	symbol := args[5]
+	if _, err := getCurrency(stub, symbol); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	from, err := getWallet(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if toAsBytes, _ := stub.GetState(args[1]); toAsBytes == nil {
+		return shim.Error("Not found wallet")
+	}
+
+	value, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Invalid value: %s", err.Error()))
+	}
+
+	if from.Balances[symbol] < value {
+		return shim.Error(fmt.Sprintf("%s is not enough balance.", args[0]))
+	}
+
+	from.Balances[symbol] -= value
+	if from.Locked == nil {
+		from.Locked = make(map[string]uint64)
+	}
+	from.Locked[symbol] += value
+
+	if err := putWallet(stub, args[0], from); err != nil {
+		return shim.Error("Failed to lock balance")
+	}
+
+	required := from.RequiredApprovals
+	if required == 0 {
+		required = 1
+	}
+
+	txid := stub.GetTxID()
+	proposal := Proposal {
+		TxID		: txid,
+		From		: args[0],
+		To			: args[1],
+		Value		: value,
+		TxType		: args[3],
+		Date		: args[4],
+		Symbol		: symbol,
+		Required	: required,
+		Approvers	: []string{},
+	}
+
+	key, err := proposalKey(stub, txid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	proposalAsBytes, _ := json.Marshal(proposal)
+	if err := stub.PutState(key, proposalAsBytes); err != nil {
+		return shim.Error("Failed to record proposal")
+	}
+
+	return shim.Success([]byte(txid))
+}
+
+// ============================================================================================================================
+//	approve_transfer
+//	- params: txid
+//	- return: proposalAsBytes while approvals are still pending, walletAsBytes once released
+//	- rejects a duplicate approval from the same identity and a missing/already-released proposal
+// ============================================================================================================================
+func approve_transfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	key, err := proposalKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	proposalAsBytes, _ := stub.GetState(key)
+	if proposalAsBytes == nil {
+		return shim.Error("No proposal found for that txid")
+	}
+
+	var proposal Proposal
+	json.Unmarshal(proposalAsBytes, &proposal)
+
+	fromWallet, err := getWallet(stub, proposal.From)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	approver, err := creatorIdentity(stub)
+	if err != nil {
+		return shim.Error("Failed to read invoking identity")
+	}
+
+	authorized := false
+	for _, allowed := range fromWallet.Approvers {
+		if allowed == approver {
+			authorized = true
+			break
+		}
+	}
+	if !authorized {
+		return shim.Error("This identity is not an authorized approver for this wallet")
+	}
+
+	for _, existing := range proposal.Approvers {
+		if existing == approver {
+			return shim.Error("This identity has already approved this transfer")
+		}
+	}
+	proposal.Approvers = append(proposal.Approvers, approver)
+
+	if uint32(len(proposal.Approvers)) < proposal.Required {
+		proposalAsBytes, _ = json.Marshal(proposal)
+		if err := stub.PutState(key, proposalAsBytes); err != nil {
+			return shim.Error("Failed to record approval")
+		}
+		return shim.Success(proposalAsBytes)
+	}
+
+	toWallet, err := getWallet(stub, proposal.To)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fromWallet.Locked[proposal.Symbol] -= proposal.Value
+	if err := putWallet(stub, proposal.From, fromWallet); err != nil {
+		return shim.Error("Failed to release locked balance")
+	}
+
+	if toWallet.Balances == nil {
+		toWallet.Balances = make(map[string]uint64)
+	}
+	toWallet.Balances[proposal.Symbol] += proposal.Value
+	toWalletAsBytes, _ := json.Marshal(toWallet)
+	if err := putWallet(stub, proposal.To, toWallet); err != nil {
+		return shim.Error("Failed to credit recipient")
+	}
+
+	if err := stub.DelState(key); err != nil {
+		return shim.Error("Failed to clear proposal")
+	}
+
+	toType, _ := strconv.Atoi(proposal.TxType)
+	toType += 1
+
+	fromTx := Transaction {
+		TxID	: proposal.TxID,
+		From	: proposal.From,
+		To		: proposal.To,
+		Value	: proposal.Value,
+		TxType	: proposal.TxType,
+		Date	: proposal.Date,
+		Symbol	: proposal.Symbol,
+	}
+	if err := recordTx(stub, proposal.From, fromTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	toTx := fromTx
+	toTx.TxType = strconv.Itoa(toType)
+	if err := recordTx(stub, proposal.To, toTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	return shim.Success(toWalletAsBytes)
+}
+
+// ============================================================================================================================
+//	getWallet / putWallet
+//	- shared load/save used by the two-phase transfer lifecycle below
+// ============================================================================================================================
+func getWallet(stub shim.ChaincodeStubInterface, key string) (Wallet, error) {
+	var wallet Wallet
+
+	walletAsBytes, err := stub.GetState(key)
+	if err != nil {
+		return wallet, err
+	}
+	if walletAsBytes == nil {
+		return wallet, fmt.Errorf("Not Found wallet : %s", key)
+	}
+
+	json.Unmarshal(walletAsBytes, &wallet)
+	return wallet, nil
+}
+
+func putWallet(stub shim.ChaincodeStubInterface, key string, wallet Wallet) error {
+	walletAsBytes, _ := json.Marshal(wallet)
+	return stub.PutState(key, walletAsBytes)
+}
+
+// ============================================================================================================================
+//	pendingKey
+//	- builds the composite key a PendingTransfer is persisted under: pending~TXID
+// ============================================================================================================================
+func pendingKey(stub shim.ChaincodeStubInterface, txid string) (string, error) {
+	return stub.CreateCompositeKey("pending", []string{txid})
+}
+
+// ============================================================================================================================
+//	transfer_pending
+//	- params: from, to, value, transfer_type, date, symbol, fee
+//	- return: txid
+//	- debits (value+fee) from the sender's balance into its locked balance; funds only move on transfer_commit
+// ============================================================================================================================
+func transfer_pending(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) != 7 {
+		return shim.Error("Incorrect number of arguments. Expecting 7")
+	}
+
+	from := args[0]
+	to := args[1]
+	value, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Invalid value: %s", err.Error()))
+	}
+	txType := args[3]
+	date := args[4]
+	symbol := args[5]
+	fee, err := strconv.ParseUint(args[6], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Invalid fee: %s", err.Error()))
+	}
+
+	if _, err := getCurrency(stub, symbol); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fromWallet, err := getWallet(stub, from)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if toAsBytes, _ := stub.GetState(to); toAsBytes == nil {
+		return shim.Error("Not found wallet")
+	}
+
+	if fromWallet.ApprovalThreshold > 0 && value >= fromWallet.ApprovalThreshold {
+		return shim.Error(fmt.Sprintf("%s requires approval for transfers >= %d; use propose_transfer/approve_transfer instead", from, fromWallet.ApprovalThreshold))
+	}
+
+	total := value + fee
+	if fromWallet.Balances[symbol] < total {
+		return shim.Error(fmt.Sprintf("%s is not enough balance.", from))
+	}
+
+	fromWallet.Balances[symbol] -= total
+	if fromWallet.Locked == nil {
+		fromWallet.Locked = make(map[string]uint64)
+	}
+	fromWallet.Locked[symbol] += total
+
+	if err := putWallet(stub, from, fromWallet); err != nil {
+		return shim.Error("Failed to lock balance")
+	}
+
+	txid := stub.GetTxID()
+	pending := PendingTransfer {
+		TxID	: txid,
+		From	: from,
+		To		: to,
+		Value	: value,
+		Fee		: fee,
+		TxType	: txType,
+		Date	: date,
+		Symbol	: symbol,
+	}
+
+	key, err := pendingKey(stub, txid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pendingAsBytes, _ := json.Marshal(pending)
+	if err := stub.PutState(key, pendingAsBytes); err != nil {
+		return shim.Error("Failed to record pending transfer")
+	}
+
+	return shim.Success([]byte(txid))
+}
+
+// ============================================================================================================================
+//	transfer_commit
+//	- params: txid
+//	- return: txid
+//	- credits the recipient, routes any fee to the coinbase wallet, and rejects a missing/already-resolved pending transfer
+// ============================================================================================================================
+func transfer_commit(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	key, err := pendingKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pendingAsBytes, _ := stub.GetState(key)
+	if pendingAsBytes == nil {
+		return shim.Error("No pending transfer found for that txid")
+	}
+
+	var pending PendingTransfer
+	json.Unmarshal(pendingAsBytes, &pending)
+
+	fromWallet, err := getWallet(stub, pending.From)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	toWallet, err := getWallet(stub, pending.To)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	total := pending.Value + pending.Fee
+	fromWallet.Locked[pending.Symbol] -= total
+	if err := putWallet(stub, pending.From, fromWallet); err != nil {
+		return shim.Error("Failed to release locked balance")
+	}
+
+	if toWallet.Balances == nil {
+		toWallet.Balances = make(map[string]uint64)
+	}
+	toWallet.Balances[pending.Symbol] += pending.Value
+	if err := putWallet(stub, pending.To, toWallet); err != nil {
+		return shim.Error("Failed to credit recipient")
+	}
+
+	if pending.Fee > 0 {
+		coinbase, err := getWallet(stub, coinbaseWalletKey)
+		if err != nil {
+			coinbase = Wallet{Balances: make(map[string]uint64)}
+		}
+		if coinbase.Balances == nil {
+			coinbase.Balances = make(map[string]uint64)
+		}
+		coinbase.Balances[pending.Symbol] += pending.Fee
+		if err := putWallet(stub, coinbaseWalletKey, coinbase); err != nil {
+			return shim.Error("Failed to route fee")
+		}
+	}
+
+	if err := stub.DelState(key); err != nil {
+		return shim.Error("Failed to clear pending transfer")
+	}
+
+	toType, _ := strconv.Atoi(pending.TxType)
+	toType += 1
+
+	fromTx := Transaction {
+		TxID	: pending.TxID,
+		From	: pending.From,
+		To		: pending.To,
+		Value	: pending.Value,
+		TxType	: pending.TxType,
+		Date	: pending.Date,
+		Symbol	: pending.Symbol,
+		Fee		: pending.Fee,
+	}
+	if err := recordTx(stub, pending.From, fromTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	toTx := fromTx
+	toTx.TxType = strconv.Itoa(toType)
+	if err := recordTx(stub, pending.To, toTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	return shim.Success([]byte(pending.TxID))
+}
+
+// ============================================================================================================================
+//	transfer_cancel
+//	- params: txid
+//	- return: txid
+//	- refunds (value+fee) to the sender and rejects a missing/already-resolved pending transfer
+// ============================================================================================================================
+func transfer_cancel(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if err := checkHalted(stub); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+
+	key, err := pendingKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pendingAsBytes, _ := stub.GetState(key)
+	if pendingAsBytes == nil {
+		return shim.Error("No pending transfer found for that txid")
+	}
+
+	var pending PendingTransfer
+	json.Unmarshal(pendingAsBytes, &pending)
+
+	fromWallet, err := getWallet(stub, pending.From)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	total := pending.Value + pending.Fee
+	fromWallet.Locked[pending.Symbol] -= total
+	fromWallet.Balances[pending.Symbol] += total
+	if err := putWallet(stub, pending.From, fromWallet); err != nil {
+		return shim.Error("Failed to refund locked balance")
+	}
+
+	if err := stub.DelState(key); err != nil {
+		return shim.Error("Failed to clear pending transfer")
+	}
+
+	cancelType, _ := strconv.Atoi(pending.TxType)
+	cancelType += 2
+
+	fromTx := Transaction {
+		TxID	: pending.TxID,
+		From	: pending.From,
+		To		: pending.To,
+		Value	: pending.Value,
+		TxType	: strconv.Itoa(cancelType),
+		Date	: pending.Date,
+		Symbol	: pending.Symbol,
+		Fee		: pending.Fee,
+	}
+	if err := recordTx(stub, pending.From, fromTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	toTx := fromTx
+	toTx.TxType = strconv.Itoa(cancelType + 1)
+	if err := recordTx(stub, pending.To, toTx); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to record transaction: %s", err.Error()))
+	}
+
+	return shim.Success([]byte(pending.TxID))
+}
+
+// ============================================================================================================================
+// 	get_account
+//	- params: key
+//	- return: balancesAsBytes (JSON map of all per-symbol balances)
+// ============================================================================================================================
+func get_account(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	var wallet Wallet
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	 }
+
+	 walletAsBytes, _ := stub.GetState(args[0]);
+	 if walletAsBytes == nil {
+		return shim.Error("Could not locate Wallet")
+	 }
+
+	 json.Unmarshal(walletAsBytes, &wallet)
+	 valueAsBytes, _ := json.Marshal(wallet.Balances)
+
+	 return shim.Success(valueAsBytes)
+}
+
+// ----- Transaction list result ----- //
+type TxListResult struct {
+	Transactions	[]Transaction	`json:"transactions"`
+	Bookmark		string			`json:"bookmark"`
+}
+
+// ============================================================================================================================
+// 	get_txList
+//	- params: key, pageSize, bookmark, fromDate, toDate, counterparty, txType
+//	- empty string means "no filter" for fromDate/toDate/counterparty/txType
+//	- fromDate/toDate/counterparty/txType are applied after the pagination fetches pageSize records from the
+//	  tx~{key} range, not before, so a returned page can be shorter than pageSize or even empty while the
+//	  returned bookmark is non-empty; callers must keep paging on a non-empty bookmark regardless of how many
+//	  (if any) transactions came back, rather than stopping on a short/empty page
+//	- return: txListResultAsBytes
+// ============================================================================================================================
+func get_txList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 7 {
+		return shim.Error("Incorrect number of arguments. Expecting 7")
+	}
+
+	key := args[0]
+	pageSize, err := strconv.ParseInt(args[1], 10, 32)
+	if err != nil {
+		return shim.Error("Invalid page size")
+	}
+	bookmark := args[2]
+	fromDate := args[3]
+	toDate := args[4]
+	counterparty := args[5]
+	txType := args[6]
+
+	resultsIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination("tx", []string{key}, int32(pageSize), bookmark)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var transactions []Transaction
+	for resultsIterator.HasNext() {
+		result, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		var tx Transaction
+		json.Unmarshal(result.Value, &tx)
+
+		if fromDate != "" && tx.Date < fromDate {
+			continue
+		}
+		if toDate != "" && tx.Date > toDate {
+			continue
+		}
+		if counterparty != "" && tx.From != counterparty && tx.To != counterparty {
+			continue
+		}
+		if txType != "" && tx.TxType != txType {
+			continue
+		}
+
+		transactions = append(transactions, tx)
+	}
+
+	result := TxListResult {
+		Transactions	: transactions,
+		Bookmark		: metadata.GetBookmark(),
+	}
+
+	resultAsBytes, _ := json.Marshal(result)
+	return shim.Success(resultAsBytes)
+}